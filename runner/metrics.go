@@ -1,10 +1,16 @@
 package main
 
 import (
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/push"
 )
 
 type metrics struct {
@@ -12,11 +18,179 @@ type metrics struct {
 	histGetSiteEventsLatency      *prometheus.HistogramVec
 	ctrGetSiteEventsReceivedTotal *prometheus.CounterVec
 	histRunEventLatency           *prometheus.HistogramVec
+	gaugeRunEventsInFlight        *prometheus.GaugeVec
+	ctrRunEventsFinishedTotal     *prometheus.CounterVec
 	histWpcliStatMaxRSS           *prometheus.HistogramVec
 	histWpcliStatCpuTime          *prometheus.HistogramVec
 	gaugeRunWorkerStateCount      *prometheus.GaugeVec
 	gaugeRunWorkerBusyPct         prometheus.Gauge
 	ctrRunWorkersAllBusyHits      prometheus.Counter
+	histRunWorkerQueueWaitSeconds *prometheus.HistogramVec
+	ctrRunWorkerRejectedTotal     *prometheus.CounterVec
+	gaugeActiveSites              prometheus.Gauge
+	ctrLabelOverflowTotal         *prometheus.CounterVec
+
+	pusher       *push.Pusher
+	pushStopCh   chan struct{}
+	shutdownOnce sync.Once
+
+	activeSitesMu     sync.Mutex
+	activeSites       map[string]time.Time
+	activeSitesWindow time.Duration
+	activeSitesStopCh chan struct{}
+
+	maxSiteLabelCardinality int
+	siteLabelsMu            sync.Mutex
+	siteLabelsSeen          map[string]map[string]struct{}
+}
+
+// overflowLabelValue is substituted for a site/site_url label once
+// MaxSiteLabelCardinality distinct values have been observed for it.
+const overflowLabelValue = "__overflow__"
+
+const (
+	defaultActiveSitesWindow        = time.Hour
+	defaultActiveSitesEvictInterval = time.Minute
+	defaultPushGatewayInterval      = 15 * time.Second
+)
+
+// defaultIfNonPositive returns def when d is zero or negative, else d. It
+// guards every caller-supplied duration that ends up seeding a
+// time.NewTicker, since a non-positive interval panics at ticker
+// construction time.
+func defaultIfNonPositive(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// PushGatewayOptions configures the optional Prometheus Pushgateway
+// integration used by short-lived / batch runs of cron-control-runner, or
+// deployments that sit behind a firewall and can't be scraped directly.
+// A nil *PushGatewayOptions disables the integration entirely; the normal
+// /metrics scrape path is unaffected either way.
+type PushGatewayOptions struct {
+	URL      string
+	JobName  string
+	Grouping map[string]string
+	// Interval is how often metrics are pushed. Zero means
+	// defaultPushGatewayInterval (15s).
+	Interval                 time.Duration
+	BasicAuthUser            string
+	BasicAuthPass            string
+	IncludeProcessCollectors bool
+}
+
+const (
+	envPushGatewayURL       = "CRON_CONTROL_RUNNER_PUSHGATEWAY_URL"
+	envPushGatewayJobName   = "CRON_CONTROL_RUNNER_PUSHGATEWAY_JOB"
+	envPushGatewayGrouping  = "CRON_CONTROL_RUNNER_PUSHGATEWAY_GROUPING"
+	envPushGatewayInterval  = "CRON_CONTROL_RUNNER_PUSHGATEWAY_INTERVAL_SECONDS"
+	envPushGatewayUser      = "CRON_CONTROL_RUNNER_PUSHGATEWAY_USERNAME"
+	envPushGatewayPass      = "CRON_CONTROL_RUNNER_PUSHGATEWAY_PASSWORD"
+	envPushGatewayProcessUp = "CRON_CONTROL_RUNNER_PUSHGATEWAY_INCLUDE_PROCESS_COLLECTORS"
+)
+
+// PushGatewayOptionsFromEnv builds a *PushGatewayOptions from the
+// CRON_CONTROL_RUNNER_PUSHGATEWAY_* environment variables. It returns nil
+// when CRON_CONTROL_RUNNER_PUSHGATEWAY_URL is unset, so the integration
+// stays opt-in by default.
+func PushGatewayOptionsFromEnv() *PushGatewayOptions {
+	url := os.Getenv(envPushGatewayURL)
+	if url == "" {
+		return nil
+	}
+	opts := &PushGatewayOptions{
+		URL:           url,
+		JobName:       os.Getenv(envPushGatewayJobName),
+		Interval:      defaultPushGatewayInterval,
+		BasicAuthUser: os.Getenv(envPushGatewayUser),
+		BasicAuthPass: os.Getenv(envPushGatewayPass),
+	}
+	if opts.JobName == "" {
+		opts.JobName = metricNamespace
+	}
+	if s := os.Getenv(envPushGatewayInterval); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil && secs > 0 {
+			opts.Interval = time.Duration(secs) * time.Second
+		}
+	}
+	if g := os.Getenv(envPushGatewayGrouping); g != "" {
+		opts.Grouping = make(map[string]string)
+		for _, pair := range strings.Split(g, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) == 2 {
+				opts.Grouping[kv[0]] = kv[1]
+			}
+		}
+	}
+	if b, err := strconv.ParseBool(os.Getenv(envPushGatewayProcessUp)); err == nil {
+		opts.IncludeProcessCollectors = b
+	}
+	return opts
+}
+
+// InitializeMetricsFromEnv is the standard production entrypoint: it wires
+// PushGatewayOptionsFromEnv() into InitializeMetrics, so the Pushgateway
+// mode stays opt-in via CRON_CONTROL_RUNNER_PUSHGATEWAY_URL without callers
+// having to hand-assemble InitOptions themselves.
+func InitializeMetricsFromEnv() {
+	InitializeMetrics(InitOptions{PushGateway: PushGatewayOptionsFromEnv()})
+}
+
+// InitOptions configures InitializeMetrics.
+type InitOptions struct {
+	// Registerer is where all collectors are registered. Defaults to
+	// prometheus.DefaultRegisterer, so the existing /metrics scrape path
+	// keeps working unchanged when this is left unset.
+	Registerer prometheus.Registerer
+
+	// Buckets overrides the default histogram buckets, keyed by the
+	// histogram's fully-qualified Prometheus name (e.g.
+	// "cron_control_runner_run_event_latency_seconds"). Lets operators tune
+	// the fixed [.01…240] buckets to their own SLOs without recompiling.
+	Buckets map[string][]float64
+
+	// MaxSiteLabelCardinality caps the number of distinct site/site_url
+	// label values tracked by histGetSiteEventsLatency, histRunEventLatency
+	// and ctrGetSiteEventsReceivedTotal. Once the limit is reached, new
+	// label values collapse into an overflowLabelValue bucket and increment
+	// ctrLabelOverflowTotal{label_name}. Zero (the default) means unlimited,
+	// preserving current behavior for small deployments.
+	MaxSiteLabelCardinality int
+
+	// PushGateway optionally enables pushing all registered collectors to a
+	// Prometheus Pushgateway on an interval; nil disables the integration.
+	PushGateway *PushGatewayOptions
+
+	// ActiveSitesWindow is the sliding window used by gaugeActiveSites: a
+	// site counts as active if MarkSiteActive was called for it within this
+	// long. Zero means defaultActiveSitesWindow (1 hour).
+	ActiveSitesWindow time.Duration
+
+	// ActiveSitesEvictInterval is how often the active-sites map is swept
+	// for entries that have fallen out of ActiveSitesWindow. Zero means
+	// defaultActiveSitesEvictInterval (1 minute).
+	ActiveSitesEvictInterval time.Duration
+}
+
+// metric name constants, used as Buckets override keys.
+const (
+	metricNameGetSitesLatency      = metricNamespace + "_get_sites_latency_seconds"
+	metricNameGetSiteEventsLatency = metricNamespace + "_get_site_events_latency_seconds"
+	metricNameRunEventLatency      = metricNamespace + "_run_event_latency_seconds"
+	metricNameWpcliStatMaxRSS      = metricNamespace + "_wpcli_stat_maxrss_mb"
+	metricNameWpcliStatCpuTime     = metricNamespace + "_wpcli_stat_cputime_seconds"
+	metricNameRunWorkerQueueWait   = metricNamespace + "_run_worker_queue_wait_seconds"
+)
+
+// bucketsFor returns overrides[name] when present and non-empty, else def.
+func bucketsFor(name string, def []float64, overrides map[string][]float64) []float64 {
+	if b, ok := overrides[name]; ok && len(b) > 0 {
+		return b
+	}
+	return def
 }
 
 var Metrics *metrics = nil
@@ -48,26 +222,121 @@ func (m *metrics) RecordGetSites(isSuccess bool, elapsed time.Duration) {
 
 func (m *metrics) RecordGetSiteEvents(site string, isSuccess bool, elapsed time.Duration, numEvents int) {
 	if m != nil {
-		siteLabel := prometheus.Labels{"site": site}
+		siteLabel := prometheus.Labels{"site": m.guardSiteLabel("site", site)}
 		m.histGetSiteEventsLatency.With(makeLabels(isSuccess, siteLabel)).Observe(elapsed.Seconds())
 		if numEvents > 0 {
 			m.ctrGetSiteEventsReceivedTotal.With(siteLabel).Add(float64(numEvents))
 		}
+		m.MarkSiteActive(site)
 	}
 }
 
-func (m *metrics) RecordRunEvent(siteUrl string, isSuccess bool, reason string, elapsed time.Duration) {
-	if m != nil {
-		if siteUrl == "" {
-			siteUrl = "unknown"
+// guardSiteLabel returns value unchanged until MaxSiteLabelCardinality
+// distinct values have been observed for labelName, after which further new
+// values collapse into overflowLabelValue and increment
+// ctrLabelOverflowTotal. A MaxSiteLabelCardinality of zero disables the
+// guard entirely.
+func (m *metrics) guardSiteLabel(labelName, value string) string {
+	if m.maxSiteLabelCardinality <= 0 {
+		return value
+	}
+	m.siteLabelsMu.Lock()
+	defer m.siteLabelsMu.Unlock()
+	seen := m.siteLabelsSeen[labelName]
+	if seen == nil {
+		seen = make(map[string]struct{})
+		m.siteLabelsSeen[labelName] = seen
+	}
+	if _, ok := seen[value]; ok {
+		return value
+	}
+	if len(seen) >= m.maxSiteLabelCardinality {
+		m.ctrLabelOverflowTotal.With(prometheus.Labels{"label_name": labelName}).Inc()
+		return overflowLabelValue
+	}
+	seen[value] = struct{}{}
+	return value
+}
+
+// MarkSiteActive records that site produced or ran work just now, so it
+// counts towards gaugeActiveSites until it falls out of the sliding window.
+func (m *metrics) MarkSiteActive(site string) {
+	if m == nil || site == "" {
+		return
+	}
+	m.activeSitesMu.Lock()
+	m.activeSites[site] = time.Now()
+	m.activeSitesMu.Unlock()
+}
+
+// runActiveSitesEviction periodically evicts sites that have fallen out of
+// the active window and updates gaugeActiveSites, until stopCh is closed by
+// Shutdown(). stopCh is passed in (rather than read off m.activeSitesStopCh
+// on each iteration) so this goroutine never touches a field Shutdown()
+// might be mutating concurrently.
+func (m *metrics) runActiveSitesEviction(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.evictStaleActiveSites()
+		case <-stopCh:
+			return
 		}
-		if reason == "" {
-			reason = "unknown"
+	}
+}
+
+func (m *metrics) evictStaleActiveSites() {
+	cutoff := time.Now().Add(-m.activeSitesWindow)
+	m.activeSitesMu.Lock()
+	for site, lastSeen := range m.activeSites {
+		if lastSeen.Before(cutoff) {
+			delete(m.activeSites, site)
+		}
+	}
+	count := len(m.activeSites)
+	m.activeSitesMu.Unlock()
+	m.gaugeActiveSites.Set(float64(count))
+}
+
+// RunEventStarted marks a run-event as in-flight and returns a closure that
+// should be deferred (or called directly) once the event finishes with its
+// terminal status ("success", "failure", "timeout" or "cancelled"). The
+// closure decrements the in-flight gauge, observes the latency histogram,
+// and increments ctrRunEventsFinishedTotal so operators can compute error
+// rates without a histogram sum query.
+func (m *metrics) RunEventStarted(siteUrl string, reason string) func(status string) {
+	if m == nil {
+		return func(string) {}
+	}
+	if siteUrl == "" {
+		siteUrl = "unknown"
+	}
+	if reason == "" {
+		reason = "unknown"
+	}
+	// Guard once up front and reuse the result everywhere below, so every
+	// metric touched by this event agrees on whether siteUrl is within the
+	// cardinality budget or has collapsed into the overflow bucket.
+	guardedSiteUrl := m.guardSiteLabel("site_url", siteUrl)
+	start := time.Now()
+	m.gaugeRunEventsInFlight.With(prometheus.Labels{"site_url": guardedSiteUrl, "reason": reason}).Inc()
+	return func(status string) {
+		if status == "" {
+			status = "unknown"
 		}
-		m.histRunEventLatency.With(makeLabels(isSuccess, prometheus.Labels{
-			"site_url": siteUrl,
+		m.gaugeRunEventsInFlight.With(prometheus.Labels{"site_url": guardedSiteUrl, "reason": reason}).Dec()
+		m.histRunEventLatency.With(makeLabels(status == "success", prometheus.Labels{
+			"site_url": guardedSiteUrl,
+			"reason":   reason,
+		})).Observe(time.Since(start).Seconds())
+		m.MarkSiteActive(siteUrl)
+		m.ctrRunEventsFinishedTotal.With(prometheus.Labels{
+			"site_url": guardedSiteUrl,
 			"reason":   reason,
-		})).Observe(elapsed.Seconds())
+			"status":   status,
+		}).Inc()
 	}
 }
 
@@ -98,73 +367,251 @@ func (m *metrics) RecordRunWorkerStats(currBusy int32, max int32) {
 	}
 }
 
+// RecordWorkerEnqueue marks the moment a job is handed to the worker pool
+// queue and returns a closure to call once a worker actually dequeues it,
+// observing histRunWorkerQueueWaitSeconds under the given reason label. This
+// gives operators backpressure visibility (e.g. p99 queue-wait > threshold)
+// beyond the boolean "all workers busy" signal.
+func (m *metrics) RecordWorkerEnqueue() func(reason string) {
+	if m == nil {
+		return func(string) {}
+	}
+	start := time.Now()
+	return func(reason string) {
+		if reason == "" {
+			reason = "unknown"
+		}
+		m.histRunWorkerQueueWaitSeconds.With(prometheus.Labels{"reason": reason}).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RecordWorkerRejected increments ctrRunWorkerRejectedTotal when a job is
+// dropped because the worker queue is at capacity.
+func (m *metrics) RecordWorkerRejected(reason string) {
+	if m == nil {
+		return
+	}
+	if reason == "" {
+		reason = "unknown"
+	}
+	m.ctrRunWorkerRejectedTotal.With(prometheus.Labels{"reason": reason}).Inc()
+}
+
+// runPusher periodically pushes m.pusher's gatherer (the dedicated
+// Pushgateway-only registry set up in InitializeMetrics) to the configured
+// Pushgateway until stopCh is closed by Shutdown(). stopCh is
+// passed in (rather than read off m.pushStopCh on each iteration) so this
+// goroutine never touches a field Shutdown() might be mutating concurrently.
+func (m *metrics) runPusher(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.pusher.Push(); err != nil {
+				logger.Printf("Pushgateway: periodic push failed: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// PushNow performs a single synchronous push to the configured Pushgateway.
+// It's a no-op if the Pushgateway integration wasn't configured, so it's
+// safe to call unconditionally on shutdown to flush the last batch.
+func (m *metrics) PushNow() error {
+	if m == nil || m.pusher == nil {
+		return nil
+	}
+	return m.pusher.Push()
+}
+
+// Shutdown stops any background goroutines started by InitializeMetrics
+// (the Pushgateway pusher and the active-sites eviction loop). It is safe
+// to call even if some of those goroutines were never started, and safe to
+// call more than once or concurrently - the actual teardown only ever runs
+// once, via shutdownOnce, so there's no race with the goroutines reading
+// these channels nor with a second Shutdown() call.
+func (m *metrics) Shutdown() {
+	if m == nil {
+		return
+	}
+	m.shutdownOnce.Do(func() {
+		if m.pushStopCh != nil {
+			close(m.pushStopCh)
+		}
+		if m.activeSitesStopCh != nil {
+			close(m.activeSitesStopCh)
+		}
+	})
+}
+
 const metricNamespace = "cron_control_runner"
 
-func InitializeMetrics() {
+// InitializeMetrics registers all collectors on opts.Registerer (defaulting
+// to prometheus.DefaultRegisterer, so existing /metrics scrape deployments
+// are unaffected). If opts.PushGateway is non-nil, it additionally
+// registers the same collectors on a dedicated, unexported registry - so the
+// pusher only ever exports our namespaced metrics, not process/Go collector
+// globals unless explicitly opted into - and starts a background goroutine
+// that periodically pushes it to a Prometheus Pushgateway.
+func InitializeMetrics(opts InitOptions) {
 	if Metrics != nil {
 		logger.Printf("Metrics already initialized, ignoring call to InitializeMetrics()")
 		return
 	}
 	logger.Printf("Initializing metrics")
+
+	activeSitesWindow := defaultIfNonPositive(opts.ActiveSitesWindow, defaultActiveSitesWindow)
+	activeSitesEvictInterval := defaultIfNonPositive(opts.ActiveSitesEvictInterval, defaultActiveSitesEvictInterval)
+
+	registerer := opts.Registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	factory := promauto.With(registerer)
 	Metrics = &metrics{
-		histGetSitesLatency: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		histGetSitesLatency: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: metricNamespace,
 			Subsystem: "get_sites",
 			Name:      "latency_seconds",
 			Help:      "Histogram of time taken to enumerate sites",
-			Buckets:   []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60},
+			Buckets:   bucketsFor(metricNameGetSitesLatency, []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60}, opts.Buckets),
 		}, []string{"status"}),
-		histGetSiteEventsLatency: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		histGetSiteEventsLatency: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: metricNamespace,
 			Subsystem: "get_site_events",
 			Name:      "latency_seconds",
 			Help:      "Histogram of time taken to enumerate events for a site",
-			Buckets:   []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60},
+			Buckets:   bucketsFor(metricNameGetSiteEventsLatency, []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60}, opts.Buckets),
 		}, []string{"site", "status"}),
-		ctrGetSiteEventsReceivedTotal: promauto.NewCounterVec(prometheus.CounterOpts{
+		ctrGetSiteEventsReceivedTotal: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: metricNamespace,
 			Subsystem: "get_site_events",
 			Name:      "events_received_total",
 			Help:      "Number of events retrieved by site",
 		}, []string{"site"}),
-		histRunEventLatency: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		histRunEventLatency: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: metricNamespace,
 			Subsystem: "run_event",
 			Name:      "latency_seconds",
 			Help:      "Histogram of time taken to run events",
-			Buckets:   []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60, 120, 240},
+			Buckets:   bucketsFor(metricNameRunEventLatency, []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60, 120, 240}, opts.Buckets),
 		}, []string{"site_url", "status", "reason"}),
-		histWpcliStatMaxRSS: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		gaugeRunEventsInFlight: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: "run_event",
+			Name:      "in_flight",
+			Help:      "Number of run-events currently executing",
+		}, []string{"site_url", "reason"}),
+		ctrRunEventsFinishedTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: "run_event",
+			Name:      "finished_total",
+			Help:      "Number of run-events that have finished, by terminal status",
+		}, []string{"site_url", "reason", "status"}),
+		histWpcliStatMaxRSS: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: metricNamespace,
 			Subsystem: "wpcli_stat",
 			Name:      "maxrss_mb",
 			Help:      "MaxRSS (in MiB) of invoked wp-cli commands",
-			Buckets:   []float64{100, 200, 300, 400, 500, 600, 700, 800, 900, 1000},
+			Buckets:   bucketsFor(metricNameWpcliStatMaxRSS, []float64{100, 200, 300, 400, 500, 600, 700, 800, 900, 1000}, opts.Buckets),
 		}, []string{"status"}),
-		histWpcliStatCpuTime: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		histWpcliStatCpuTime: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: metricNamespace,
 			Subsystem: "wpcli_stat",
 			Name:      "cputime_seconds",
 			Help:      "CPU time (in seconds) of invoked wp-cli commands",
-			Buckets:   []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60, 120, 240},
+			Buckets:   bucketsFor(metricNameWpcliStatCpuTime, []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60, 120, 240}, opts.Buckets),
 		}, []string{"cpu_mode", "status"}),
-		gaugeRunWorkerStateCount: promauto.NewGaugeVec(prometheus.GaugeOpts{
+		gaugeRunWorkerStateCount: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: metricNamespace,
 			Subsystem: "run_worker",
 			Name:      "state_count",
 			Help:      "Breakdown of run-workers by state",
 		}, []string{"state"}),
-		gaugeRunWorkerBusyPct: promauto.NewGauge(prometheus.GaugeOpts{
+		gaugeRunWorkerBusyPct: factory.NewGauge(prometheus.GaugeOpts{
 			Namespace: metricNamespace,
 			Subsystem: "run_worker",
 			Name:      "busy_pct",
 			Help:      "Instantaneous percentage of busy workers",
 		}),
-		ctrRunWorkersAllBusyHits: promauto.NewCounter(prometheus.CounterOpts{
+		ctrRunWorkersAllBusyHits: factory.NewCounter(prometheus.CounterOpts{
 			Namespace: metricNamespace,
 			Subsystem: "run_worker",
 			Name:      "all_busy_hits",
 			Help:      "Number of times all workers have been instantaneously saturated",
 		}),
+		histRunWorkerQueueWaitSeconds: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Subsystem: "run_worker",
+			Name:      "queue_wait_seconds",
+			Help:      "Histogram of time jobs spend waiting in the worker queue before being dequeued",
+			Buckets:   bucketsFor(metricNameRunWorkerQueueWait, []float64{.01, .05, .1, .5, 1, 2, 5, 10, 20, 60}, opts.Buckets),
+		}, []string{"reason"}),
+		ctrRunWorkerRejectedTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: "run_worker",
+			Name:      "rejected_total",
+			Help:      "Number of jobs dropped because the worker queue was at capacity",
+		}, []string{"reason"}),
+		gaugeActiveSites: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "active_sites",
+			Help:      "Number of distinct sites that have produced or run work within the active window",
+		}),
+		ctrLabelOverflowTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "label_overflow_total",
+			Help:      "Number of times a site/site_url label value was collapsed into the overflow bucket due to MaxSiteLabelCardinality",
+		}, []string{"label_name"}),
+		activeSites:             make(map[string]time.Time),
+		activeSitesWindow:       activeSitesWindow,
+		activeSitesStopCh:       make(chan struct{}),
+		maxSiteLabelCardinality: opts.MaxSiteLabelCardinality,
+		siteLabelsSeen:          make(map[string]map[string]struct{}),
+	}
+	go Metrics.runActiveSitesEviction(activeSitesEvictInterval, Metrics.activeSitesStopCh)
+
+	if opts.PushGateway == nil {
+		return
+	}
+	pushOpts := opts.PushGateway
+	pushInterval := defaultIfNonPositive(pushOpts.Interval, defaultPushGatewayInterval)
+
+	pushRegistry := prometheus.NewRegistry()
+	pushRegistry.MustRegister(
+		Metrics.histGetSitesLatency,
+		Metrics.histGetSiteEventsLatency,
+		Metrics.ctrGetSiteEventsReceivedTotal,
+		Metrics.histRunEventLatency,
+		Metrics.gaugeRunEventsInFlight,
+		Metrics.ctrRunEventsFinishedTotal,
+		Metrics.histWpcliStatMaxRSS,
+		Metrics.histWpcliStatCpuTime,
+		Metrics.gaugeRunWorkerStateCount,
+		Metrics.gaugeRunWorkerBusyPct,
+		Metrics.ctrRunWorkersAllBusyHits,
+		Metrics.histRunWorkerQueueWaitSeconds,
+		Metrics.ctrRunWorkerRejectedTotal,
+		Metrics.gaugeActiveSites,
+		Metrics.ctrLabelOverflowTotal,
+	)
+	if pushOpts.IncludeProcessCollectors {
+		pushRegistry.MustRegister(prometheus.NewGoCollector())
+		pushRegistry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	}
+
+	pusher := push.New(pushOpts.URL, pushOpts.JobName).Gatherer(pushRegistry)
+	for name, value := range pushOpts.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if pushOpts.BasicAuthUser != "" {
+		pusher = pusher.BasicAuth(pushOpts.BasicAuthUser, pushOpts.BasicAuthPass)
 	}
+	Metrics.pusher = pusher
+	Metrics.pushStopCh = make(chan struct{})
+	logger.Printf("Pushgateway: pushing to %s every %s (job=%s)", pushOpts.URL, pushInterval, pushOpts.JobName)
+	go Metrics.runPusher(pushInterval, Metrics.pushStopCh)
 }
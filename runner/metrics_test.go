@@ -0,0 +1,206 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRunPusherStopsOnShutdown(t *testing.T) {
+	m := &metrics{pushStopCh: make(chan struct{})}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.runPusher(time.Hour, m.pushStopCh)
+	}()
+
+	m.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runPusher did not return after Shutdown")
+	}
+}
+
+func TestDefaultIfNonPositiveGuardsZeroAndNegative(t *testing.T) {
+	cases := []struct {
+		d, def, want time.Duration
+	}{
+		{0, defaultPushGatewayInterval, defaultPushGatewayInterval},
+		{-time.Second, defaultPushGatewayInterval, defaultPushGatewayInterval},
+		{5 * time.Second, defaultPushGatewayInterval, 5 * time.Second},
+	}
+	for _, c := range cases {
+		if got := defaultIfNonPositive(c.d, c.def); got != c.want {
+			t.Fatalf("defaultIfNonPositive(%v, %v) = %v, want %v", c.d, c.def, got, c.want)
+		}
+	}
+}
+
+func TestRunPusherDoesNotPanicOnZeroValuePushGatewayOptions(t *testing.T) {
+	// Regression test: InitOptions{PushGateway: &PushGatewayOptions{URL: ...,
+	// JobName: ...}} built directly (without PushGatewayOptionsFromEnv)
+	// leaves Interval at its zero value. InitializeMetrics must resolve it
+	// via defaultIfNonPositive before handing it to runPusher, or
+	// time.NewTicker panics with "non-positive interval for NewTicker".
+	pushOpts := &PushGatewayOptions{URL: "http://127.0.0.1:1/", JobName: "test"}
+	interval := defaultIfNonPositive(pushOpts.Interval, defaultPushGatewayInterval)
+
+	m := &metrics{pushStopCh: make(chan struct{})}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.runPusher(interval, m.pushStopCh)
+	}()
+
+	m.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runPusher did not return after Shutdown")
+	}
+}
+
+func TestShutdownIsIdempotent(t *testing.T) {
+	m := &metrics{pushStopCh: make(chan struct{}), activeSitesStopCh: make(chan struct{})}
+	m.Shutdown()
+	m.Shutdown()
+}
+
+func TestActiveSitesEvictionStopsOnShutdown(t *testing.T) {
+	m := &metrics{
+		gaugeActiveSites:  prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_active_sites"}),
+		activeSites:       make(map[string]time.Time),
+		activeSitesWindow: time.Millisecond,
+		activeSitesStopCh: make(chan struct{}),
+	}
+	m.MarkSiteActive("example.com")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.runActiveSitesEviction(time.Millisecond, m.activeSitesStopCh)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	m.activeSitesMu.Lock()
+	_, stillPresent := m.activeSites["example.com"]
+	m.activeSitesMu.Unlock()
+	if stillPresent {
+		t.Fatal("expected site to be evicted once past activeSitesWindow")
+	}
+
+	m.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runActiveSitesEviction did not return after Shutdown")
+	}
+}
+
+func TestRecordWorkerEnqueueObservesQueueWait(t *testing.T) {
+	m := &metrics{
+		histRunWorkerQueueWaitSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "test_run_worker_queue_wait_seconds",
+		}, []string{"reason"}),
+	}
+
+	dequeued := m.RecordWorkerEnqueue()
+	time.Sleep(10 * time.Millisecond)
+	dequeued("scheduled")
+
+	if got := testutil.CollectAndCount(m.histRunWorkerQueueWaitSeconds); got != 1 {
+		t.Fatalf("expected exactly one queue-wait series, got %d", got)
+	}
+	var metric dto.Metric
+	if err := m.histRunWorkerQueueWaitSeconds.With(prometheus.Labels{"reason": "scheduled"}).(prometheus.Histogram).Write(&metric); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if sum := metric.GetHistogram().GetSampleSum(); sum <= 0 {
+		t.Fatalf("expected a positive queue-wait observation, got %v", sum)
+	}
+}
+
+func TestRecordWorkerRejectedIncrementsCounter(t *testing.T) {
+	m := &metrics{
+		ctrRunWorkerRejectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_run_worker_rejected_total",
+		}, []string{"reason"}),
+	}
+
+	m.RecordWorkerRejected("queue_full")
+	m.RecordWorkerRejected("")
+
+	if got := testutil.ToFloat64(m.ctrRunWorkerRejectedTotal.With(prometheus.Labels{"reason": "queue_full"})); got != 1 {
+		t.Fatalf("expected ctrRunWorkerRejectedTotal{reason=queue_full} to be 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.ctrRunWorkerRejectedTotal.With(prometheus.Labels{"reason": "unknown"})); got != 1 {
+		t.Fatalf("expected empty reason to fall back to \"unknown\", got %v", got)
+	}
+}
+
+func TestRunEventStartedGuardsLabelConsistently(t *testing.T) {
+	m := &metrics{
+		gaugeRunEventsInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "test_run_events_in_flight",
+		}, []string{"site_url", "reason"}),
+		histRunEventLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "test_run_event_latency_seconds",
+		}, []string{"site_url", "status", "reason"}),
+		ctrRunEventsFinishedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_run_events_finished_total",
+		}, []string{"site_url", "reason", "status"}),
+		ctrLabelOverflowTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_label_overflow_total",
+		}, []string{"label_name"}),
+		activeSites:             make(map[string]time.Time),
+		maxSiteLabelCardinality: 1,
+		siteLabelsSeen:          make(map[string]map[string]struct{}),
+	}
+
+	stopA := m.RunEventStarted("site-a.example", "cron")
+	stopB := m.RunEventStarted("site-b.example", "cron") // exceeds the cap of 1, should collapse
+	stopA("success")
+	stopB("failure")
+
+	if got := testutil.ToFloat64(m.ctrLabelOverflowTotal.With(prometheus.Labels{"label_name": "site_url"})); got != 1 {
+		t.Fatalf("expected ctrLabelOverflowTotal to be incremented exactly once, got %v", got)
+	}
+	overflowFinished := testutil.ToFloat64(m.ctrRunEventsFinishedTotal.With(prometheus.Labels{
+		"site_url": overflowLabelValue, "reason": "cron", "status": "failure",
+	}))
+	if overflowFinished != 1 {
+		t.Fatalf("expected ctrRunEventsFinishedTotal to record site-b under %q, got %v", overflowLabelValue, overflowFinished)
+	}
+	overflowInFlight := testutil.ToFloat64(m.gaugeRunEventsInFlight.With(prometheus.Labels{
+		"site_url": overflowLabelValue, "reason": "cron",
+	}))
+	if overflowInFlight != 0 {
+		t.Fatalf("expected in-flight gauge for %q back to 0 after stop, got %v", overflowLabelValue, overflowInFlight)
+	}
+}